@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +11,7 @@ import (
 	circuitbreaker "github.com/shurutech/circuit-breaker/v1"
 )
 
-func fallbackFunc(req *http.Request) *circuitbreaker.CircuitBreakerResponse {
+func fallbackFunc(ctx context.Context, req *http.Request) *circuitbreaker.CircuitBreakerResponse {
 	// This is where you define your fallback logic. For example, return a static response or call an alternative service.
 	// The following is a simple static response for demonstration purposes.
 	return &circuitbreaker.CircuitBreakerResponse{
@@ -43,7 +44,14 @@ func main() {
 		},
 	}
 
-	cb := circuitbreaker.NewCircuitBreaker(customConfig, "example", rdb)
+	cb, err := circuitbreaker.NewCircuitBreaker(customConfig, "example", rdb)
+	if err != nil {
+		log.Fatalf("Failed to create circuit breaker: %v", err)
+	}
+	if err := cb.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start circuit breaker: %v", err)
+	}
+	defer cb.Close()
 	cb.SetFallbackFunc(fallbackFunc)
 
 	requestURL := "http://example.com"
@@ -52,7 +60,7 @@ func main() {
 		log.Fatalf("Failed to create request: %v", err)
 	}
 
-	response := cb.DoRequest(req)
+	response := cb.DoRequestWithContext(context.Background(), req)
 	if response.Error != nil {
 		log.Printf("Request failed with error: %v", response.Error)
 		return
@@ -60,7 +68,15 @@ func main() {
 
 	if response.ResponseType == circuitbreaker.Fallback {
 		fmt.Println("Fallback response received.")
-	} else {
-		fmt.Printf("Received response with status code: %d\n", response.HttpStatus)
+		return
+	}
+
+	fmt.Printf("Received response with status code: %d\n", response.HttpStatus)
+
+	var data map[string]interface{}
+	if err := response.DecodeJSON(&data); err != nil {
+		log.Printf("Failed to decode response body: %v", err)
+		return
 	}
+	fmt.Printf("Decoded response body: %v\n", data)
 }
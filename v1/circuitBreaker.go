@@ -1,24 +1,35 @@
 package circuitbreaker
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-var ctx = context.Background()
-
+// DoRequest executes req through the circuit breaker using
+// context.Background(). Deprecated: use DoRequestWithContext instead.
 func (cb *CircuitBreaker) DoRequest(req *http.Request) *CircuitBreakerResponse {
-	cb.mutex.Lock()
-	state := cb.getState()
-	cb.mutex.Unlock()
+	return cb.DoRequestWithContext(context.Background(), req)
+}
+
+func (cb *CircuitBreaker) DoRequestWithContext(ctx context.Context, req *http.Request) *CircuitBreakerResponse {
+	state := cb.getState(ctx)
 
 	if state == Open && cb.fallbackFunc == nil {
+		if cb.config.CacheSuccessfulResponses {
+			if cached := cb.getCachedResponse(ctx, req); cached != nil {
+				return cached
+			}
+		}
 		return &CircuitBreakerResponse{
 			ResponseType: Error,
 			Error: &ErrorDetail{
@@ -27,54 +38,70 @@ func (cb *CircuitBreaker) DoRequest(req *http.Request) *CircuitBreakerResponse {
 			},
 		}
 	} else if state == Open && cb.fallbackFunc != nil {
-		return cb.fallbackFunc(req)
+		return cb.fallbackFunc(ctx, req)
+	}
+
+	req = req.WithContext(ctx)
+
+	httpClient := cb.config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
 
 	var lastErr error
 	for _, interval := range cb.config.RetryIntervals {
-		resp, err := http.DefaultClient.Do(req)
-		if err == nil && resp.StatusCode < 500 {
-			cb.recordSuccess()
-
-			defer resp.Body.Close()
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return &CircuitBreakerResponse{
-					ResponseType: Error,
-					Error: &ErrorDetail{
-						Code:    http.StatusInternalServerError,
-						Message: "Failed to read response body",
-						Raw:     err,
-					},
-				}
+		resp, err := httpClient.Do(req)
+		if !cb.isFailure(resp, err) {
+			cb.recordSuccess(ctx)
+
+			successResp := &CircuitBreakerResponse{
+				HttpStatus:   resp.StatusCode,
+				ResponseType: Success,
+				Response:     resp,
 			}
 
-			var data interface{}
-			if err := json.Unmarshal(body, &data); err != nil {
-				return &CircuitBreakerResponse{
-					ResponseType: Error,
-					Error: &ErrorDetail{
-						Code:    http.StatusInternalServerError,
-						Message: "Failed to unmarshal response body",
-						Raw:     err,
-					},
+			// Caching requires materializing the body, which trades away
+			// streaming for this response; callers that don't opt in get the
+			// live resp.Body back untouched.
+			if cb.config.CacheSuccessfulResponses {
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					return &CircuitBreakerResponse{
+						ResponseType: Error,
+						Error: &ErrorDetail{
+							Code:    http.StatusInternalServerError,
+							Message: "Failed to read response body",
+							Raw:     err,
+						},
+					}
 				}
+				resp.Body = io.NopCloser(bytes.NewReader(body))
+				successResp.Raw = string(body)
+				cb.cacheResponse(ctx, req, successResp)
 			}
 
-			return &CircuitBreakerResponse{
-				HttpStatus:   resp.StatusCode,
-				ResponseType: Success,
-				Data:         data,
-				Raw:          string(body),
-			}
+			return successResp
 		}
 		lastErr = err
+		if err == nil {
+			resp.Body.Close()
+		}
 		time.Sleep(interval)
-		cb.recordFailure()
+		cb.recordFailure(ctx)
 	}
 
-	if lastErr != nil && cb.fallbackFunc != nil {
-		return cb.fallbackFunc(req)
+	// Every attempt above was classified a failure (transport error or
+	// Config.IsFailure), not just ones with a transport error, so fall back
+	// whenever one is configured regardless of whether lastErr is nil.
+	if cb.fallbackFunc != nil {
+		return cb.fallbackFunc(ctx, req)
+	}
+
+	if cb.config.CacheSuccessfulResponses {
+		if cached := cb.getCachedResponse(ctx, req); cached != nil {
+			return cached
+		}
 	}
 
 	return &CircuitBreakerResponse{
@@ -87,22 +114,73 @@ func (cb *CircuitBreaker) DoRequest(req *http.Request) *CircuitBreakerResponse {
 	}
 }
 
-func (cb *CircuitBreaker) SetFallbackFunc(f func(*http.Request) *CircuitBreakerResponse) {
+func (cb *CircuitBreaker) SetFallbackFunc(f func(context.Context, *http.Request) *CircuitBreakerResponse) {
 	cb.fallbackFunc = f
 }
 
-func (cb *CircuitBreaker) syncStateWithRedis() {
+// isFailure classifies a round trip as a circuit-accounting failure,
+// deferring to Config.IsFailure when set. A transport error with no response
+// (resp == nil) is always a failure and never reaches Config.IsFailure, so
+// implementations don't need to guard against a nil resp themselves.
+func (cb *CircuitBreaker) isFailure(resp *http.Response, err error) bool {
+	if resp == nil {
+		return true
+	}
+	if cb.config.IsFailure != nil {
+		return cb.config.IsFailure(resp, err)
+	}
+	return err != nil || resp.StatusCode >= 500
+}
+
+func (cb *CircuitBreaker) pubsubChannel() string {
+	return "circuitbreaker:" + cb.name
+}
+
+// startPubSub is a no-op when redisClient doesn't support Pub/Sub (e.g. the
+// minimal RedisClient used in tests), in which case getState falls back to
+// the cache seeded at startup.
+func (cb *CircuitBreaker) startPubSub() {
+	psClient, ok := cb.redisClient.(PubSubClient)
+	if !ok {
+		return
+	}
+
+	cb.pubsub = psClient.Subscribe(context.Background(), cb.pubsubChannel())
+	cb.stopPubSub = make(chan struct{})
+
+	go cb.listenForStateChanges()
+}
+
+func (cb *CircuitBreaker) listenForStateChanges() {
+	ch := cb.pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			cb.cachedState.Store(State(msg.Payload))
+		case <-cb.stopPubSub:
+			return
+		}
+	}
+}
+
+func (cb *CircuitBreaker) syncStateWithRedis(ctx context.Context) error {
 	stateVal, err := cb.redisClient.Get(ctx, cb.name).Result()
 	if err == redis.Nil {
-		cb.setState(Closed)
-	} else if err == nil {
-		cb.setState(State(stateVal))
-	} else {
-		log.Printf("Error fetching state for %s from Redis: %v", cb.name, err)
+		cb.setState(ctx, Closed)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("circuitbreaker: failed to sync initial state for %s: %w", cb.name, err)
 	}
+
+	cb.setState(ctx, State(stateVal))
+	return nil
 }
 
-func (cb *CircuitBreaker) setState(state State) {
+func (cb *CircuitBreaker) setState(ctx context.Context, state State) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
@@ -112,22 +190,65 @@ func (cb *CircuitBreaker) setState(state State) {
 		log.Printf("Error updating state for %s in Redis: %v", cb.name, err)
 		return
 	}
+	cb.cachedState.Store(state)
+
+	if psClient, ok := cb.redisClient.(PubSubClient); ok {
+		if err := psClient.Publish(ctx, cb.pubsubChannel(), strState).Err(); err != nil {
+			log.Printf("Error publishing state change for %s: %v", cb.name, err)
+		}
+	}
 }
 
-func (cb *CircuitBreaker) getState() State {
+// getState returns the cached State, seeding it with a single Redis GET the
+// first time it's called.
+func (cb *CircuitBreaker) getState(ctx context.Context) State {
+	if v := cb.cachedState.Load(); v != nil {
+		return v.(State)
+	}
+
 	stateVal, err := cb.redisClient.Get(ctx, cb.name).Result()
 	if err != nil {
 		log.Printf("Error fetching state for %s from Redis: %v", cb.name, err)
 		return Closed
 	}
-	return State(stateVal)
+
+	state := State(stateVal)
+	cb.cachedState.Store(state)
+	return state
+}
+
+// Start begins the breaker's background work: the OPEN-to-HALF-OPEN timer
+// and, when redisClient supports it, the Pub/Sub state-change listener.
+// Idempotent: calls after the first are a no-op, since repeating them would
+// leak the previous Pub/Sub subscription and its listener goroutine.
+func (cb *CircuitBreaker) Start(ctx context.Context) error {
+	cb.startOnce.Do(func() {
+		cb.startTimer()
+		cb.startPubSub()
+	})
+	return nil
+}
+
+func (cb *CircuitBreaker) Close() error {
+	var err error
+	cb.closeOnce.Do(func() {
+		cb.stopTimer()
+		if cb.stopPubSub != nil {
+			close(cb.stopPubSub)
+		}
+		if cb.pubsub != nil {
+			err = cb.pubsub.Close()
+		}
+	})
+	return err
 }
 
 func (cb *CircuitBreaker) startTimer() {
-	state := cb.getState()
+	ctx := context.Background()
+	state := cb.getState(ctx)
 	if state == Open {
 		cb.timer = time.AfterFunc(cb.config.OpenToHalfOpenWait, func() {
-			cb.setState(HalfOpen)
+			cb.setState(context.Background(), HalfOpen)
 			cb.failures = 0
 			cb.success = 0
 			log.Println("Circuit breaker transitioned to HALF-OPEN")
@@ -145,10 +266,21 @@ func (cb *CircuitBreaker) stopTimer() {
 	}
 }
 
-func (cb *CircuitBreaker) recordFailure() {
-	state := cb.getState()
-	now := time.Now()
+func (cb *CircuitBreaker) recordFailure(ctx context.Context) {
+	state := cb.getState(ctx)
 	if state == Closed {
+		if cb.config.UseSlidingWindow {
+			cb.recordWindowOutcome(ctx, true)
+			failures, total := cb.windowStats(ctx)
+			if cb.shouldTripFromWindow(failures, total) {
+				cb.setState(ctx, Open)
+				cb.startTimer()
+				log.Println("Circuit breaker transitioned to OPEN")
+			}
+			return
+		}
+
+		now := time.Now()
 		if cb.failures == 0 || now.Sub(cb.lastFail) <= time.Minute {
 			cb.failures++
 		} else {
@@ -158,7 +290,7 @@ func (cb *CircuitBreaker) recordFailure() {
 
 		if cb.failures >= cb.config.MaxFailures {
 			cb.failures = 0
-			cb.setState(Open)
+			cb.setState(ctx, Open)
 			cb.startTimer()
 			log.Println("Circuit breaker transitioned to OPEN")
 		}
@@ -167,23 +299,155 @@ func (cb *CircuitBreaker) recordFailure() {
 		if cb.failures >= cb.config.HalfOpenMaxFailures {
 			cb.failures = 0
 			cb.success = 0
-			cb.setState(Open)
+			cb.setState(ctx, Open)
 			cb.startTimer()
 			log.Println("Circuit breaker transitioned to OPEN from HALF-OPEN due to failures")
 		}
 	}
 }
 
-func (cb *CircuitBreaker) recordSuccess() {
-	state := cb.getState()
+func (cb *CircuitBreaker) recordSuccess(ctx context.Context) {
+	state := cb.getState(ctx)
+	if state == Closed && cb.config.UseSlidingWindow {
+		cb.recordWindowOutcome(ctx, false)
+	}
 	if state == HalfOpen {
 		cb.success++
 		if cb.success >= cb.config.HalfOpenMaxSuccess {
 			cb.failures = 0
 			cb.success = 0
-			cb.setState(Closed)
+			cb.setState(ctx, Closed)
 			cb.stopTimer()
 			log.Println("Circuit breaker transitioned to CLOSE from HALF-OPEN due to successes")
 		}
 	}
 }
+
+func (cb *CircuitBreaker) windowKey() string {
+	return fmt.Sprintf("cb:%s:window", cb.name)
+}
+
+func (cb *CircuitBreaker) windowFailuresKey() string {
+	return fmt.Sprintf("cb:%s:window:failures", cb.name)
+}
+
+// recordWindowOutcome records one request's outcome in the sliding window
+// (scored by Unix-nano timestamp) and trims entries older than
+// Config.WindowDuration.
+func (cb *CircuitBreaker) recordWindowOutcome(ctx context.Context, failed bool) {
+	now := time.Now()
+	seq := atomic.AddInt64(&cb.windowSeq, 1)
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), seq)
+	score := float64(now.UnixNano())
+
+	if err := cb.redisClient.ZAdd(ctx, cb.windowKey(), redis.Z{Score: score, Member: member}).Err(); err != nil {
+		log.Printf("Error recording window entry for %s: %v", cb.name, err)
+	}
+	if failed {
+		if err := cb.redisClient.ZAdd(ctx, cb.windowFailuresKey(), redis.Z{Score: score, Member: member}).Err(); err != nil {
+			log.Printf("Error recording window failure for %s: %v", cb.name, err)
+		}
+	}
+
+	cutoff := fmt.Sprintf("%d", now.Add(-cb.config.WindowDuration).UnixNano())
+	if err := cb.redisClient.ZRemRangeByScore(ctx, cb.windowKey(), "-inf", cutoff).Err(); err != nil {
+		log.Printf("Error trimming window for %s: %v", cb.name, err)
+	}
+	if err := cb.redisClient.ZRemRangeByScore(ctx, cb.windowFailuresKey(), "-inf", cutoff).Err(); err != nil {
+		log.Printf("Error trimming window failures for %s: %v", cb.name, err)
+	}
+}
+
+func (cb *CircuitBreaker) windowStats(ctx context.Context) (failures, total int) {
+	t, err := cb.redisClient.ZCard(ctx, cb.windowKey()).Result()
+	if err != nil {
+		log.Printf("Error reading window total for %s: %v", cb.name, err)
+		return 0, 0
+	}
+
+	f, err := cb.redisClient.ZCard(ctx, cb.windowFailuresKey()).Result()
+	if err != nil {
+		log.Printf("Error reading window failures for %s: %v", cb.name, err)
+		return 0, int(t)
+	}
+
+	return int(f), int(t)
+}
+
+func (cb *CircuitBreaker) shouldTripFromWindow(failures, total int) bool {
+	if failures >= cb.config.MaxFailures {
+		return true
+	}
+	return total >= cb.config.MinRequestsInWindow && float64(failures)/float64(total) >= cb.config.FailureRatioThreshold
+}
+
+// cacheKey identifies a request by method, URL, and a hash of its body, so
+// distinct payloads to the same URL are cached independently. It reads and
+// restores req.Body, since the body can only be read once.
+func (cb *CircuitBreaker) cacheKey(req *http.Request) (string, error) {
+	var bodyBytes []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		bodyBytes = b
+	}
+
+	hash := sha256.Sum256(bodyBytes)
+	return fmt.Sprintf("cb:%s:response-cache:%s:%s:%x", cb.name, req.Method, req.URL.String(), hash), nil
+}
+
+// cacheResponse is best-effort: caching failures are logged, not returned,
+// since they must never fail the request they're caching.
+func (cb *CircuitBreaker) cacheResponse(ctx context.Context, req *http.Request, resp *CircuitBreakerResponse) {
+	key, err := cb.cacheKey(req)
+	if err != nil {
+		log.Printf("Error building response cache key for %s: %v", cb.name, err)
+		return
+	}
+
+	rawBody, _ := resp.Raw.(string)
+	cached, err := json.Marshal(cachedResponse{HttpStatus: resp.HttpStatus, Raw: []byte(rawBody)})
+	if err != nil {
+		log.Printf("Error marshaling cached response for %s: %v", cb.name, err)
+		return
+	}
+
+	if err := cb.redisClient.Set(ctx, key, cached, cb.config.ResponseCacheTTL).Err(); err != nil {
+		log.Printf("Error storing cached response for %s: %v", cb.name, err)
+	}
+}
+
+func (cb *CircuitBreaker) getCachedResponse(ctx context.Context, req *http.Request) *CircuitBreakerResponse {
+	key, err := cb.cacheKey(req)
+	if err != nil {
+		log.Printf("Error building response cache key for %s: %v", cb.name, err)
+		return nil
+	}
+
+	val, err := cb.redisClient.Get(ctx, key).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error fetching cached response for %s: %v", cb.name, err)
+		}
+		return nil
+	}
+
+	var cached cachedResponse
+	if err := json.Unmarshal([]byte(val), &cached); err != nil {
+		log.Printf("Error unmarshaling cached response for %s: %v", cb.name, err)
+		return nil
+	}
+
+	var data interface{}
+	_ = json.Unmarshal(cached.Raw, &data)
+
+	return &CircuitBreakerResponse{
+		HttpStatus:   cached.HttpStatus,
+		ResponseType: Fallback,
+		Data:         data,
+		Raw:          string(cached.Raw),
+	}
+}
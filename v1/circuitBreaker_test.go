@@ -3,6 +3,8 @@ package circuitbreaker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -35,6 +37,21 @@ func (m *MockRedisClient) Set(ctx context.Context, key string, value interface{}
 	return args.Get(0).(*redis.StatusCmd)
 }
 
+// ZAdd, ZRemRangeByScore, and ZCard back Config.UseSlidingWindow, which none
+// of the tests below enable, so they're never exercised here.
+
+func (m *MockRedisClient) ZAdd(ctx context.Context, key string, members ...redis.Z) *redis.IntCmd {
+	return &redis.IntCmd{}
+}
+
+func (m *MockRedisClient) ZRemRangeByScore(ctx context.Context, key, min, max string) *redis.IntCmd {
+	return &redis.IntCmd{}
+}
+
+func (m *MockRedisClient) ZCard(ctx context.Context, key string) *redis.IntCmd {
+	return &redis.IntCmd{}
+}
+
 var _ = Describe("CircuitBreaker", func() {
 	var (
 		cb                *CircuitBreaker
@@ -62,6 +79,11 @@ var _ = Describe("CircuitBreaker", func() {
 
 			w.WriteHeader(http.StatusOK)
 		})
+
+		mux.HandleFunc("/xml", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte("<ok/>"))
+		})
 	})
 
 	AfterEach(func() {
@@ -76,7 +98,10 @@ var _ = Describe("CircuitBreaker", func() {
 				cbState.SetVal("CLOSED")
 				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
 				mockedRedisClient.On("Set", mock.Anything, "test", "CLOSED", mock.Anything).Return(&redis.StatusCmd{})
-				cb = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				var err error
+				cb, err = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
 				req, _ := http.NewRequest("GET", server.URL, nil)
 				resp := cb.DoRequest(req)
 				Expect(resp.ResponseType).To(Equal(Success))
@@ -91,7 +116,10 @@ var _ = Describe("CircuitBreaker", func() {
 				cbState.SetVal("OPEN")
 				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
 				mockedRedisClient.On("Set", mock.Anything, "test", "OPEN", mock.Anything).Return(&redis.StatusCmd{})
-				cb = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				var err error
+				cb, err = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
 				req, _ := http.NewRequest("GET", server.URL+"/fail", nil)
 				resp := cb.DoRequest(req)
 				Expect(resp.ResponseType).To(Equal(Error))
@@ -106,8 +134,11 @@ var _ = Describe("CircuitBreaker", func() {
 				cbState.SetVal("OPEN")
 				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
 				mockedRedisClient.On("Set", mock.Anything, "test", "OPEN", mock.Anything).Return(&redis.StatusCmd{})
-				cb = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
-				cb.SetFallbackFunc(func(req *http.Request) *CircuitBreakerResponse {
+				var err error
+				cb, err = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
+				cb.SetFallbackFunc(func(ctx context.Context, req *http.Request) *CircuitBreakerResponse {
 					return &CircuitBreakerResponse{
 						ResponseType: "fallback",
 					}
@@ -118,6 +149,40 @@ var _ = Describe("CircuitBreaker", func() {
 			})
 		})
 
+		Context("when Config.IsFailure classifies every retry as a failure and fallback is provided", func() {
+			It("should execute the fallback function instead of returning a generic error", func() {
+				mux.HandleFunc("/not-found", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+				})
+
+				mockedRedisClient = new(MockRedisClient)
+				var cbState redis.StringCmd
+				cbState.SetVal("CLOSED")
+				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
+				mockedRedisClient.On("Set", mock.Anything, "test", "CLOSED", mock.Anything).Return(&redis.StatusCmd{})
+
+				config := DefaultConfig
+				config.RetryIntervals = []time.Duration{time.Millisecond, time.Millisecond}
+				config.IsFailure = func(resp *http.Response, err error) bool {
+					return resp != nil && resp.StatusCode == http.StatusNotFound
+				}
+
+				var err error
+				cb, err = NewCircuitBreaker(config, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
+				cb.SetFallbackFunc(func(ctx context.Context, req *http.Request) *CircuitBreakerResponse {
+					return &CircuitBreakerResponse{
+						ResponseType: Fallback,
+					}
+				})
+
+				req, _ := http.NewRequest("GET", server.URL+"/not-found", nil)
+				resp := cb.DoRequest(req)
+				Expect(resp.ResponseType).To(Equal(Fallback))
+			})
+		})
+
 		Context("with concurrent requests", func() {
 			It("should handle concurrent requests correctly", func() {
 				var wg sync.WaitGroup
@@ -130,7 +195,10 @@ var _ = Describe("CircuitBreaker", func() {
 				cbState.SetVal("CLOSED")
 				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
 				mockedRedisClient.On("Set", mock.Anything, "test", "CLOSED", mock.Anything).Return(&redis.StatusCmd{})
-				cb = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				var err error
+				cb, err = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
 				for i := 0; i < 4; i++ {
 					wg.Add(1)
 					go func() {
@@ -150,6 +218,86 @@ var _ = Describe("CircuitBreaker", func() {
 				Expect(successCount + failureCount).To(Equal(4))
 			})
 		})
+
+		Context("with a non-JSON response body", func() {
+			It("should return it as Success instead of failing to unmarshal it", func() {
+				mockedRedisClient = new(MockRedisClient)
+				var cbState redis.StringCmd
+				cbState.SetVal("CLOSED")
+				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
+				mockedRedisClient.On("Set", mock.Anything, "test", "CLOSED", mock.Anything).Return(&redis.StatusCmd{})
+				var err error
+				cb, err = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
+
+				req, _ := http.NewRequest("GET", server.URL+"/xml", nil)
+				resp := cb.DoRequest(req)
+				Expect(resp.ResponseType).To(Equal(Success))
+				Expect(resp.Error).To(BeNil())
+				Expect(resp.Response).ToNot(BeNil())
+
+				defer resp.Response.Body.Close()
+				body, err := io.ReadAll(resp.Response.Body)
+				Expect(err).To(BeNil())
+				Expect(string(body)).To(Equal("<ok/>"))
+			})
+		})
+
+		Context("with a JSON response body", func() {
+			It("should decode it via DecodeJSON", func() {
+				mockedRedisClient = new(MockRedisClient)
+				var cbState redis.StringCmd
+				cbState.SetVal("CLOSED")
+				mockedRedisClient.On("Get", mock.Anything, "test").Return(&cbState)
+				mockedRedisClient.On("Set", mock.Anything, "test", "CLOSED", mock.Anything).Return(&redis.StatusCmd{})
+				var err error
+				cb, err = NewCircuitBreaker(DefaultConfig, "test", mockedRedisClient)
+				Expect(err).To(BeNil())
+				Expect(cb.Start(context.Background())).To(Succeed())
+
+				req, _ := http.NewRequest("GET", server.URL, nil)
+				resp := cb.DoRequest(req)
+				Expect(resp.ResponseType).To(Equal(Success))
+
+				var data map[string]interface{}
+				Expect(resp.DecodeJSON(&data)).To(Succeed())
+				Expect(data["key"]).To(Equal("value"))
+			})
+		})
+	})
+
+	Describe("isFailure", func() {
+		It("always treats a nil response as a failure, without consulting Config.IsFailure", func() {
+			called := false
+			cfg := DefaultConfig
+			cfg.IsFailure = func(resp *http.Response, err error) bool {
+				called = true
+				return false
+			}
+			cb := &CircuitBreaker{config: cfg}
+
+			Expect(cb.isFailure(nil, errors.New("dial tcp: connection refused"))).To(BeTrue())
+			Expect(called).To(BeFalse())
+		})
+
+		It("defers to Config.IsFailure when a response is present", func() {
+			cfg := DefaultConfig
+			cfg.IsFailure = func(resp *http.Response, err error) bool {
+				return resp.StatusCode == http.StatusNotFound
+			}
+			cb := &CircuitBreaker{config: cfg}
+
+			Expect(cb.isFailure(&http.Response{StatusCode: http.StatusNotFound}, nil)).To(BeTrue())
+			Expect(cb.isFailure(&http.Response{StatusCode: http.StatusOK}, nil)).To(BeFalse())
+		})
+
+		It("defaults to treating 5xx statuses as failures when Config.IsFailure is nil", func() {
+			cb := &CircuitBreaker{config: DefaultConfig}
+
+			Expect(cb.isFailure(&http.Response{StatusCode: http.StatusInternalServerError}, nil)).To(BeTrue())
+			Expect(cb.isFailure(&http.Response{StatusCode: http.StatusOK}, nil)).To(BeFalse())
+		})
 	})
 })
 
@@ -0,0 +1,78 @@
+package circuitbreaker
+
+import (
+	"crypto/tls"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("universalOptionsFromRedisOptions", func() {
+	It("maps standalone fields straight through", func() {
+		opts := RedisOptions{
+			Addrs:        []string{"localhost:6379"},
+			DB:           2,
+			Username:     "user",
+			Password:     "pass",
+			DialTimeout:  time.Second,
+			ReadTimeout:  2 * time.Second,
+			WriteTimeout: 3 * time.Second,
+		}
+
+		got := universalOptionsFromRedisOptions(opts)
+		Expect(got.Addrs).To(Equal(opts.Addrs))
+		Expect(got.DB).To(Equal(opts.DB))
+		Expect(got.Username).To(Equal(opts.Username))
+		Expect(got.Password).To(Equal(opts.Password))
+		Expect(got.DialTimeout).To(Equal(opts.DialTimeout))
+		Expect(got.ReadTimeout).To(Equal(opts.ReadTimeout))
+		Expect(got.WriteTimeout).To(Equal(opts.WriteTimeout))
+		Expect(got.MasterName).To(BeEmpty())
+		Expect(got.TLSConfig).To(BeNil())
+	})
+
+	It("maps Sentinel credentials and MasterName", func() {
+		opts := RedisOptions{
+			Addrs:            []string{"sentinel1:26379", "sentinel2:26379"},
+			MasterName:       "mymaster",
+			SentinelUsername: "sentinel-user",
+			SentinelPassword: "sentinel-pass",
+		}
+
+		got := universalOptionsFromRedisOptions(opts)
+		Expect(got.MasterName).To(Equal("mymaster"))
+		Expect(got.SentinelUsername).To(Equal("sentinel-user"))
+		Expect(got.SentinelPassword).To(Equal("sentinel-pass"))
+		Expect(got.Addrs).To(Equal(opts.Addrs))
+	})
+
+	It("passes multiple Addrs through for Cluster mode", func() {
+		opts := RedisOptions{
+			Addrs: []string{"node1:6379", "node2:6379", "node3:6379"},
+		}
+
+		got := universalOptionsFromRedisOptions(opts)
+		Expect(got.Addrs).To(Equal(opts.Addrs))
+		Expect(got.MasterName).To(BeEmpty())
+	})
+
+	It("sets a minimum TLS 1.2 config when TLSEnabled", func() {
+		got := universalOptionsFromRedisOptions(RedisOptions{TLSEnabled: true})
+		Expect(got.TLSConfig).ToNot(BeNil())
+		Expect(got.TLSConfig.MinVersion).To(Equal(uint16(tls.VersionTLS12)))
+	})
+
+	It("leaves TLSConfig nil when TLSEnabled is false", func() {
+		got := universalOptionsFromRedisOptions(RedisOptions{})
+		Expect(got.TLSConfig).To(BeNil())
+	})
+})
+
+var _ = Describe("NewCircuitBreakerFromURL", func() {
+	It("wraps redis.ParseURL errors instead of swallowing them", func() {
+		_, err := NewCircuitBreakerFromURL(DefaultConfig, "test", "not-a-valid-redis-url")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("circuitbreaker: failed to parse redis url"))
+	})
+})
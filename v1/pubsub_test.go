@@ -0,0 +1,45 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("Pub/Sub state propagation", func() {
+	var mr *miniredis.Miniredis
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		mr.Close()
+	})
+
+	It("updates a peer instance's cached state without a new Get", func() {
+		owner, err := NewCircuitBreaker(DefaultConfig, "shared", redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		Expect(err).To(BeNil())
+		Expect(owner.Start(context.Background())).To(Succeed())
+		defer owner.Close()
+
+		peer, err := NewCircuitBreaker(DefaultConfig, "shared", redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		Expect(err).To(BeNil())
+		Expect(peer.Start(context.Background())).To(Succeed())
+		defer peer.Close()
+
+		Expect(peer.getState(context.Background())).To(Equal(Closed))
+
+		owner.setState(context.Background(), Open)
+
+		Eventually(func() State {
+			return peer.getState(context.Background())
+		}, time.Second, 10*time.Millisecond).Should(Equal(Open))
+	})
+})
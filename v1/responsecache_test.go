@@ -0,0 +1,51 @@
+package circuitbreaker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("response cache", func() {
+	var mr *miniredis.Miniredis
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		mr.Close()
+	})
+
+	It("round-trips a non-UTF-8 body without corruption", func() {
+		binaryBody := []byte{0x00, 0x01, 0xFF, 0xFE, 'h', 'i'}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(binaryBody)
+		}))
+		defer server.Close()
+
+		config := DefaultConfig
+		config.CacheSuccessfulResponses = true
+
+		cb, err := NewCircuitBreaker(config, "cache-binary", redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		Expect(err).To(BeNil())
+		Expect(cb.Start(context.Background())).To(Succeed())
+		defer cb.Close()
+
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp := cb.DoRequest(req)
+		Expect(resp.ResponseType).To(Equal(Success))
+
+		cached := cb.getCachedResponse(context.Background(), req)
+		Expect(cached).ToNot(BeNil())
+		Expect(cached.Raw).To(Equal(string(binaryBody)))
+	})
+})
@@ -0,0 +1,86 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("sliding window trip mode", func() {
+	var (
+		mr *miniredis.Miniredis
+		cb *CircuitBreaker
+	)
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).To(BeNil())
+
+		config := DefaultConfig
+		config.UseSlidingWindow = true
+		config.WindowDuration = time.Minute
+		config.MaxFailures = 5
+		config.MinRequestsInWindow = 4
+		config.FailureRatioThreshold = 0.5
+
+		cb, err = NewCircuitBreaker(config, "window", redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		mr.Close()
+	})
+
+	It("tallies failures and totals recorded via recordWindowOutcome", func() {
+		ctx := context.Background()
+		cb.recordWindowOutcome(ctx, true)
+		cb.recordWindowOutcome(ctx, false)
+		cb.recordWindowOutcome(ctx, true)
+
+		failures, total := cb.windowStats(ctx)
+		Expect(failures).To(Equal(2))
+		Expect(total).To(Equal(3))
+	})
+
+	It("trims entries older than WindowDuration", func() {
+		cb.config.WindowDuration = 20 * time.Millisecond
+
+		ctx := context.Background()
+		cb.recordWindowOutcome(ctx, true)
+		time.Sleep(40 * time.Millisecond)
+		cb.recordWindowOutcome(ctx, false)
+
+		failures, total := cb.windowStats(ctx)
+		Expect(failures).To(Equal(0))
+		Expect(total).To(Equal(1))
+	})
+
+	It("trips once the absolute MaxFailures count is reached, even below the ratio threshold", func() {
+		Expect(cb.shouldTripFromWindow(4, 100)).To(BeFalse())
+		Expect(cb.shouldTripFromWindow(5, 100)).To(BeTrue())
+	})
+
+	It("trips on failure ratio once MinRequestsInWindow is reached", func() {
+		Expect(cb.shouldTripFromWindow(1, 3)).To(BeFalse(), "below MinRequestsInWindow")
+		Expect(cb.shouldTripFromWindow(2, 4)).To(BeTrue(), "at MinRequestsInWindow, ratio 0.5")
+		Expect(cb.shouldTripFromWindow(1, 4)).To(BeFalse(), "at MinRequestsInWindow, ratio below threshold")
+	})
+
+	It("opens the circuit once recordFailure observes a tripping window", func() {
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			cb.recordFailure(ctx)
+		}
+		Expect(cb.getState(ctx)).To(Equal(Closed))
+
+		for i := 0; i < 2; i++ {
+			cb.recordFailure(ctx)
+		}
+		Expect(cb.getState(ctx)).To(Equal(Open))
+	})
+})
@@ -2,8 +2,11 @@ package circuitbreaker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -16,6 +19,23 @@ type Config struct {
 	HalfOpenMaxSuccess  int
 	HalfOpenMaxFailures int
 	RetryIntervals      []time.Duration
+
+	// HTTPClient defaults to http.DefaultClient when nil.
+	HTTPClient *http.Client
+
+	CacheSuccessfulResponses bool
+	ResponseCacheTTL         time.Duration
+
+	UseSlidingWindow      bool
+	WindowDuration        time.Duration
+	MinRequestsInWindow   int
+	FailureRatioThreshold float64
+
+	// IsFailure overrides how a round trip is classified for circuit
+	// accounting. Only invoked when resp is non-nil; a transport error with
+	// no response is always a failure. Defaults to a transport error or a
+	// 5xx status when nil.
+	IsFailure func(resp *http.Response, err error) bool
 }
 
 var DefaultConfig = Config{
@@ -25,6 +45,14 @@ var DefaultConfig = Config{
 	HalfOpenMaxSuccess:  5,
 	HalfOpenMaxFailures: 3,
 	RetryIntervals:      []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 8 * time.Second},
+
+	CacheSuccessfulResponses: false,
+	ResponseCacheTTL:         5 * time.Minute,
+
+	UseSlidingWindow:      false,
+	WindowDuration:        1 * time.Minute,
+	MinRequestsInWindow:   10,
+	FailureRatioThreshold: 0.5,
 }
 
 type State string
@@ -51,10 +79,29 @@ type CircuitBreaker struct {
 	success      int
 	lastFail     time.Time
 	timer        *time.Timer
-	fallbackFunc func(*http.Request) *CircuitBreakerResponse
+	fallbackFunc func(context.Context, *http.Request) *CircuitBreakerResponse
 	redisClient  RedisClient
+
+	// cachedState lets getState be read lock-free on the hot path; it's
+	// seeded by one Redis GET and kept current by startPubSub's listener.
+	cachedState atomic.Value
+	pubsub      *redis.PubSub
+	stopPubSub  chan struct{}
+	startOnce   sync.Once
+	closeOnce   sync.Once
+
+	windowSeq int64
+}
+
+// Service is satisfied by CircuitBreaker: construct with NewCircuitBreaker,
+// Start to begin background work, Close to release it.
+type Service interface {
+	Start(ctx context.Context) error
+	Close() error
 }
 
+var _ Service = (*CircuitBreaker)(nil)
+
 type ErrorDetail struct {
 	Code    int    `json:"code,omitempty"`
 	Message string `json:"message,omitempty"`
@@ -67,9 +114,68 @@ type CircuitBreakerResponse struct {
 	Data         interface{}  `json:"data,omitempty"`
 	Error        *ErrorDetail `json:"error,omitempty"`
 	Raw          interface{}  `json:"raw,omitempty"`
+
+	// Response is the raw HTTP response for a Success result; its Body is
+	// unread. Fallback responses don't carry a live Response.
+	Response *http.Response `json:"-"`
+}
+
+// DecodeJSON decodes and closes r.Response's body as JSON into v.
+func (r *CircuitBreakerResponse) DecodeJSON(v interface{}) error {
+	if r.Response == nil || r.Response.Body == nil {
+		return errors.New("circuitbreaker: no response body to decode")
+	}
+	defer r.Response.Body.Close()
+	return json.NewDecoder(r.Response.Body).Decode(v)
+}
+
+// cachedResponse.Raw is []byte rather than string so encoding/json
+// base64-encodes it, since a cached body isn't guaranteed to be valid UTF-8
+// (e.g. protobuf or other binary payloads).
+type cachedResponse struct {
+	HttpStatus int    `json:"http_status"`
+	Raw        []byte `json:"raw"`
 }
 
 type RedisClient interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+
+	ZAdd(ctx context.Context, key string, members ...redis.Z) *redis.IntCmd
+	ZRemRangeByScore(ctx context.Context, key, min, max string) *redis.IntCmd
+	ZCard(ctx context.Context, key string) *redis.IntCmd
+}
+
+// PubSubClient is implemented by RedisClients that can also publish and
+// subscribe, which redis.NewUniversalClient always returns. A redisClient
+// satisfying this gets state transitions from peer instances propagated via
+// Pub/Sub; otherwise getState only sees the state seeded at startup and this
+// instance's own transitions.
+type PubSubClient interface {
+	RedisClient
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// RedisOptions describes how to connect to Redis in standalone, Sentinel, or
+// Cluster mode. Set MasterName to connect via Sentinel, or provide more than
+// one entry in Addrs to connect via Cluster; otherwise a single standalone
+// client is created.
+type RedisOptions struct {
+	Addrs []string
+
+	DB       int
+	Username string
+	Password string
+
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+
+	// TLSEnabled wraps the connection in a minimal TLS config.
+	TLSEnabled bool
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }